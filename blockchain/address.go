@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+
+	"golang.org/x/crypto/ripemd160"
+)
+
+// addressVersion is the single version byte prepended to a pubkey hash before it is
+// base58check-encoded into a human-readable address, mirroring Bitcoin's P2PKH scheme.
+const addressVersion = byte(0x00)
+
+// checksumLen is the number of checksum bytes appended to a versioned payload before
+// base58-encoding it.
+const checksumLen = 4
+
+var base58Alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
+
+// HashPubKey returns the HASH160 (SHA-256 then RIPEMD-160) of a public key, the value
+// addresses and TXOutput.PubKeyHash are built from.
+func HashPubKey(pubKey []byte) []byte {
+	sha := sha256.Sum256(pubKey)
+
+	ripemd := ripemd160.New()
+	if _, err := ripemd.Write(sha[:]); err != nil {
+		panic(err)
+	}
+	return ripemd.Sum(nil)
+}
+
+// checksum returns the first checksumLen bytes of a double SHA-256 of payload.
+func checksum(payload []byte) []byte {
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	return second[:checksumLen]
+}
+
+// AddressFromPubKeyHash base58check-encodes a pubkey hash into a human-readable address.
+func AddressFromPubKeyHash(pubKeyHash []byte) string {
+	versioned := append([]byte{addressVersion}, pubKeyHash...)
+	full := append(versioned, checksum(versioned)...)
+	return base58Encode(full)
+}
+
+// PubKeyHashFromAddress decodes address back into the raw pubkey hash it was built from,
+// dropping the leading version byte and trailing checksum.
+func PubKeyHashFromAddress(address string) []byte {
+	full := base58Decode(address)
+	return full[1 : len(full)-checksumLen]
+}
+
+func base58Encode(input []byte) string {
+	x := new(big.Int).SetBytes(input)
+	base := big.NewInt(int64(len(base58Alphabet)))
+	zero := big.NewInt(0)
+	mod := &big.Int{}
+
+	var result []byte
+	for x.Cmp(zero) != 0 {
+		x.DivMod(x, base, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range input {
+		if b != 0x00 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+
+	reverse(result)
+	return string(result)
+}
+
+func base58Decode(input string) []byte {
+	result := big.NewInt(0)
+	base := big.NewInt(int64(len(base58Alphabet)))
+
+	for _, b := range []byte(input) {
+		index := bytes.IndexByte(base58Alphabet, b)
+		result.Mul(result, base)
+		result.Add(result, big.NewInt(int64(index)))
+	}
+
+	decoded := result.Bytes()
+	for i := 0; i < len(input) && input[i] == base58Alphabet[0]; i++ {
+		decoded = append([]byte{0x00}, decoded...)
+	}
+	return decoded
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
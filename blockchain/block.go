@@ -0,0 +1,26 @@
+package blockchain
+
+import "bytes"
+
+// Block is a single block of a shard's chain.
+type Block struct {
+	ShardId      int
+	Transactions []*Transaction
+	MerkleRoot   []byte
+}
+
+// NewBlock builds a block carrying txs on shardID, computing and setting its Merkle root so
+// light clients can verify the block's contents without trusting the sender.
+func NewBlock(shardID int, txs []*Transaction) *Block {
+	return &Block{
+		ShardId:      shardID,
+		Transactions: txs,
+		MerkleRoot:   MerkleRoot(txs),
+	}
+}
+
+// VerifyMerkleRoot reports whether block.MerkleRoot matches the root recomputed from
+// block.Transactions, i.e. whether the block's advertised contents are internally consistent.
+func VerifyMerkleRoot(block *Block) bool {
+	return bytes.Equal(block.MerkleRoot, MerkleRoot(block.Transactions))
+}
@@ -0,0 +1,28 @@
+package blockchain
+
+// Rough per-field serialized sizes (in bytes) used to estimate a transaction's footprint
+// without fully serializing it, the same approximation the Sygma BTC executor uses to budget
+// block space.
+const (
+	// InputSize is the estimated serialized size of one TXInput (prevout pointer + a P2PKH
+	// signature script).
+	InputSize = 148
+	// OutputSize is the estimated serialized size of one TXOutput (value + a P2PKH
+	// locking script).
+	OutputSize = 34
+	// BaseTxSize covers the version, input/output counts, and locktime fields that are
+	// present even on a zero-input, zero-output transaction.
+	BaseTxSize = 10
+)
+
+// EstimateSize approximates the serialized size of a transaction with the given number of
+// inputs and outputs.
+func EstimateSize(numInputs, numOutputs int) int {
+	return BaseTxSize + numInputs*InputSize + numOutputs*OutputSize
+}
+
+// EstimateFee returns the fee (in the same units as TXOutput.Value) a transaction of the
+// given estimated size should pay at feeRate units-per-byte.
+func EstimateFee(size int, feeRate int) int {
+	return size * feeRate
+}
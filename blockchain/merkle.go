@@ -0,0 +1,32 @@
+package blockchain
+
+import "crypto/sha256"
+
+// MerkleRoot computes the Merkle root of a block's transaction IDs: leaves are the tx IDs in
+// order, each level pairs adjacent hashes (duplicating the last one if the level has an odd
+// count), until a single root hash remains. An empty tx list roots to the zero hash.
+func MerkleRoot(txs []*Transaction) []byte {
+	if len(txs) == 0 {
+		return make([]byte, sha256.Size)
+	}
+
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		level[i] = tx.ID
+	}
+
+	for len(level) > 1 {
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		var next [][]byte
+		for i := 0; i < len(level); i += 2 {
+			sum := sha256.Sum256(append(append([]byte{}, level[i]...), level[i+1]...))
+			next = append(next, sum[:])
+		}
+		level = next
+	}
+
+	return level[0]
+}
@@ -0,0 +1,24 @@
+package blockchain
+
+import "testing"
+
+func TestMerkleRootEmpty(t *testing.T) {
+	root := MerkleRoot(nil)
+	if len(root) != 32 {
+		t.Errorf("expected a 32-byte zero hash for an empty tx list, got %d bytes", len(root))
+	}
+}
+
+func TestVerifyMerkleRoot(t *testing.T) {
+	cbtx, _ := NewCoinbaseTX("minh", "")
+	block := NewBlock(0, []*Transaction{cbtx})
+
+	if !VerifyMerkleRoot(block) {
+		t.Errorf("VerifyMerkleRoot rejected a block's own Merkle root")
+	}
+
+	block.Transactions = append(block.Transactions, cbtx)
+	if VerifyMerkleRoot(block) {
+		t.Errorf("VerifyMerkleRoot accepted a block whose tx list no longer matches its Merkle root")
+	}
+}
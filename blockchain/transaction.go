@@ -0,0 +1,235 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"log"
+	"math/big"
+)
+
+// genesisCoinbaseData is the arbitrary data stored in the coinbase input of the genesis block.
+const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
+
+// curveByteLen is the fixed width, in bytes, a P-256 field element or signature component is
+// padded to before being concatenated into a PubKey or Signature -- big.Int.Bytes() strips
+// leading zero bytes, so without padding an occasional short component would silently
+// misalign the later split back into its two halves.
+const curveByteLen = 32
+
+// paddedBytes renders n as exactly curveByteLen bytes, left-padded with zeros.
+func paddedBytes(n *big.Int) []byte {
+	b := n.Bytes()
+	if len(b) == curveByteLen {
+		return b
+	}
+	padded := make([]byte, curveByteLen)
+	copy(padded[curveByteLen-len(b):], b)
+	return padded
+}
+
+// TXInput represents a transaction input, i.e. a reference to a previous
+// transaction's output that is being spent.
+type TXInput struct {
+	TxID       [32]byte
+	TxOutIndex int
+	ScriptSig  string
+	ShardID    int
+	PubKey     []byte
+	Signature  []byte
+}
+
+// UsesKey reports whether this input's PubKey hashes to pubKeyHash, i.e. whether it was unlocked
+// by that key. Before signing, PubKey is empty and UsesKey reports false.
+func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
+	lockingHash := HashPubKey(in.PubKey)
+	return bytes.Equal(lockingHash, pubKeyHash)
+}
+
+// TXOutput represents a transaction output, i.e. a payment to an address.
+type TXOutput struct {
+	Value      int
+	Address    string
+	ShardID    int
+	PubKeyHash []byte
+}
+
+// Lock sets out.PubKeyHash from the given address so Transaction.Verify can check future
+// inputs spending this output against it.
+func (out *TXOutput) Lock(address string) {
+	out.PubKeyHash = PubKeyHashFromAddress(address)
+}
+
+// IsLockedWithKey reports whether pubKeyHash can unlock this output.
+func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
+	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+}
+
+// NewTXOutput creates a TXOutput paying value to address on the given shard, locked to
+// address's pubkey hash.
+func NewTXOutput(value int, address string, shardID int) TXOutput {
+	txo := TXOutput{Value: value, Address: address, ShardID: shardID}
+	txo.Lock(address)
+	return txo
+}
+
+// Transaction represents a Bitcoin-style transaction moving value from a
+// set of TXInputs to a set of TXOutputs.
+type Transaction struct {
+	ID       []byte
+	TxInput  []TXInput
+	TxOutput []TXOutput
+}
+
+// SetID computes and sets the transaction's ID as the SHA-256 hash of its
+// gob-encoded contents.
+func (tx *Transaction) SetID() {
+	var encoded bytes.Buffer
+	var hash [32]byte
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	hash = sha256.Sum256(encoded.Bytes())
+	tx.ID = hash[:]
+}
+
+// IsCoinbase returns whether the transaction is a coinbase transaction,
+// i.e. one with a single input referencing no previous output.
+func (tx Transaction) IsCoinbase() bool {
+	return len(tx.TxInput) == 1 && tx.TxInput[0].TxID == [32]byte{} && tx.TxInput[0].TxOutIndex == -1
+}
+
+// TrimmedCopy returns a copy of tx with each input's Signature and PubKey cleared, which is
+// the form that gets hashed and signed/verified per input.
+func (tx *Transaction) TrimmedCopy() Transaction {
+	var inputs []TXInput
+	var outputs []TXOutput
+
+	for _, in := range tx.TxInput {
+		inputs = append(inputs, TXInput{TxID: in.TxID, TxOutIndex: in.TxOutIndex, ShardID: in.ShardID})
+	}
+	outputs = append(outputs, tx.TxOutput...)
+
+	return Transaction{ID: tx.ID, TxInput: inputs, TxOutput: outputs}
+}
+
+// Sign signs each non-coinbase input of tx with privKey, over a per-input copy of the
+// transaction in which every input but the one being signed is blanked out and the one being
+// signed carries the PubKeyHash of the TXOutput it references (from prevTXs, keyed by hex
+// transaction ID). Leaders call Verify with the same prevTXs before including tx in a block.
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
+	if tx.IsCoinbase() {
+		return
+	}
+
+	txCopy := tx.TrimmedCopy()
+
+	for inID, in := range txCopy.TxInput {
+		prevTX := prevTXs[txIDKey(in.TxID)]
+		txCopy.TxInput[inID].PubKey = prevTX.TxOutput[in.TxOutIndex].PubKeyHash
+
+		txCopy.ID = txCopy.hash()
+		txCopy.TxInput[inID].PubKey = nil
+
+		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
+		if err != nil {
+			log.Panic(err)
+		}
+		signature := append(paddedBytes(r), paddedBytes(s)...)
+
+		tx.TxInput[inID].Signature = signature
+		tx.TxInput[inID].PubKey = append(paddedBytes(privKey.PublicKey.X), paddedBytes(privKey.PublicKey.Y)...)
+	}
+}
+
+// Verify checks the ECDSA signature on every non-coinbase input of tx against the PubKeyHash
+// of the TXOutput it claims to spend, as found in prevTXs (keyed by hex transaction ID).
+// Leaders must call this before including a transaction in a block.
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	txCopy := tx.TrimmedCopy()
+	curve := elliptic.P256()
+
+	for inID, in := range tx.TxInput {
+		prevTX, ok := prevTXs[txIDKey(in.TxID)]
+		if !ok || in.TxOutIndex < 0 || in.TxOutIndex >= len(prevTX.TxOutput) {
+			return false
+		}
+
+		txCopy.TxInput[inID].PubKey = prevTX.TxOutput[in.TxOutIndex].PubKeyHash
+		txCopy.ID = txCopy.hash()
+		txCopy.TxInput[inID].PubKey = nil
+
+		if len(in.PubKey) != 2*curveByteLen || len(in.Signature) != 2*curveByteLen {
+			return false
+		}
+		if !in.UsesKey(prevTX.TxOutput[in.TxOutIndex].PubKeyHash) {
+			return false
+		}
+		x := new(big.Int).SetBytes(in.PubKey[:curveByteLen])
+		y := new(big.Int).SetBytes(in.PubKey[curveByteLen:])
+		rawPubKey := ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+
+		r := new(big.Int).SetBytes(in.Signature[:curveByteLen])
+		s := new(big.Int).SetBytes(in.Signature[curveByteLen:])
+
+		if !ecdsa.Verify(&rawPubKey, txCopy.ID, r, s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hash returns the SHA-256 hash of tx's gob-encoded contents with its ID cleared, used as the
+// digest Sign/Verify operate over, so the digest is the same whether or not SetID has run.
+func (tx *Transaction) hash() []byte {
+	txCopy := *tx
+	txCopy.ID = nil
+
+	var encoded bytes.Buffer
+	enc := gob.NewEncoder(&encoded)
+	if err := enc.Encode(txCopy); err != nil {
+		log.Panic(err)
+	}
+	hash := sha256.Sum256(encoded.Bytes())
+	return hash[:]
+}
+
+// txIDKey renders a TXInput's referenced transaction ID in the hex form prevTXs is keyed by.
+func txIDKey(txID [32]byte) string {
+	return hex.EncodeToString(txID[:])
+}
+
+// NewCoinbaseTX creates a new coinbase transaction paying "to" and seeds
+// the returned UTXOPool with its single output. A coinbase transaction has
+// no real inputs, so it is always valid to add token supply to the chain.
+func NewCoinbaseTX(to, data string) (*Transaction, *UTXOPool) {
+	if data == "" {
+		data = genesisCoinbaseData
+	}
+
+	txin := TXInput{TxID: [32]byte{}, TxOutIndex: -1, ScriptSig: data}
+	txout := NewTXOutput(subsidy, to, 0)
+	tx := Transaction{TxInput: []TXInput{txin}, TxOutput: []TXOutput{txout}}
+	tx.SetID()
+
+	utxoPool := NewUTXOPool()
+	utxoPool.AddOutput(to, tx.ID, 0, txout)
+
+	return &tx, utxoPool
+}
+
+// subsidy is the amount of token minted by a coinbase transaction.
+const subsidy = 1000
@@ -5,7 +5,57 @@ import (
 )
 
 func TestNewCoinbaseTX(t *testing.T) {
-	if cbtx, utxoPool := NewCoinbaseTX("minh", genesisCoinbaseData); cbtx == nil || utxoPool == nil {
-		t.Errorf("failed to create a coinbase transaction.")
+	cbtx, utxoPool := NewCoinbaseTX("minh", genesisCoinbaseData)
+	if cbtx == nil || utxoPool == nil {
+		t.Fatalf("failed to create a coinbase transaction.")
 	}
-}
\ No newline at end of file
+	if !cbtx.IsCoinbase() {
+		t.Errorf("coinbase transaction should report IsCoinbase() == true")
+	}
+	if len(cbtx.TxOutput) != 1 || cbtx.TxOutput[0].Value != subsidy {
+		t.Errorf("coinbase transaction should pay exactly the subsidy to a single output, got %+v", cbtx.TxOutput)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	wallet, err := NewWallet()
+	if err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+
+	prevTx := Transaction{TxOutput: []TXOutput{NewTXOutput(1000, wallet.Address(), 0)}}
+	tx := Transaction{
+		TxInput:  []TXInput{{TxID: [32]byte{1}, TxOutIndex: 0, ShardID: 0}},
+		TxOutput: []TXOutput{NewTXOutput(900, wallet.Address(), 0)},
+	}
+	prevTXs := map[string]Transaction{txIDKey(tx.TxInput[0].TxID): prevTx}
+
+	tx.Sign(wallet.PrivateKey, prevTXs)
+
+	if len(tx.TxInput[0].Signature) != 2*curveByteLen || len(tx.TxInput[0].PubKey) != 2*curveByteLen {
+		t.Fatalf("expected fixed-width signature/pubkey, got sig=%d pubkey=%d bytes", len(tx.TxInput[0].Signature), len(tx.TxInput[0].PubKey))
+	}
+	if !tx.Verify(prevTXs) {
+		t.Errorf("Verify rejected a validly signed transaction")
+	}
+}
+
+func TestVerifyRejectsTamperedSignature(t *testing.T) {
+	wallet, err := NewWallet()
+	if err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+
+	prevTx := Transaction{TxOutput: []TXOutput{NewTXOutput(1000, wallet.Address(), 0)}}
+	tx := Transaction{
+		TxInput:  []TXInput{{TxID: [32]byte{1}, TxOutIndex: 0, ShardID: 0}},
+		TxOutput: []TXOutput{NewTXOutput(900, wallet.Address(), 0)},
+	}
+	prevTXs := map[string]Transaction{txIDKey(tx.TxInput[0].TxID): prevTx}
+	tx.Sign(wallet.PrivateKey, prevTXs)
+
+	tx.TxInput[0].Signature[0] ^= 0xFF
+	if tx.Verify(prevTXs) {
+		t.Errorf("Verify accepted a tampered signature")
+	}
+}
@@ -0,0 +1,76 @@
+package blockchain
+
+// UTXOPool tracks every spendable output, keyed first by the owning
+// address and then by the transaction that created it and the output's
+// index within that transaction:
+//
+//	address -> txID (raw bytes as a string) -> outputIndex -> TXOutput
+//
+// This mirrors the comment in btctxgen describing the UTXO map shape.
+type UTXOPool struct {
+	VerifiedTransactions map[string]map[string]map[int]TXOutput
+}
+
+// NewUTXOPool creates an empty UTXOPool.
+func NewUTXOPool() *UTXOPool {
+	return &UTXOPool{VerifiedTransactions: make(map[string]map[string]map[int]TXOutput)}
+}
+
+// AddOutput records txOutput as spendable by address.
+func (pool *UTXOPool) AddOutput(address string, txID []byte, outputIndex int, txOutput TXOutput) {
+	key := string(txID)
+	if pool.VerifiedTransactions[address] == nil {
+		pool.VerifiedTransactions[address] = make(map[string]map[int]TXOutput)
+	}
+	if pool.VerifiedTransactions[address][key] == nil {
+		pool.VerifiedTransactions[address][key] = make(map[int]TXOutput)
+	}
+	pool.VerifiedTransactions[address][key][outputIndex] = txOutput
+}
+
+// RemoveOutput removes the output at outputIndex of txID from address's
+// spendable set, e.g. once it has been consumed by a new input.
+func (pool *UTXOPool) RemoveOutput(address string, txID []byte, outputIndex int) {
+	outputs, ok := pool.VerifiedTransactions[address][string(txID)]
+	if !ok {
+		return
+	}
+	delete(outputs, outputIndex)
+	if len(outputs) == 0 {
+		delete(pool.VerifiedTransactions[address], string(txID))
+	}
+}
+
+// SpendableOutput is a single UTXO belonging to an address, together with
+// the identifiers needed to reference it as a TXInput.
+type SpendableOutput struct {
+	Address  string
+	TxID     []byte
+	Index    int
+	TXOutput TXOutput
+}
+
+// SpendableOutputsForAddress returns every UTXO currently owned by address.
+func (pool *UTXOPool) SpendableOutputsForAddress(address string) []SpendableOutput {
+	var outputs []SpendableOutput
+	for txID, indices := range pool.VerifiedTransactions[address] {
+		for index, txOutput := range indices {
+			outputs = append(outputs, SpendableOutput{address, []byte(txID), index, txOutput})
+		}
+	}
+	return outputs
+}
+
+// FindOutput looks up the output at outputIndex of txID across every address in the pool, for
+// verifying a transaction's inputs without already knowing which address owns them.
+func (pool *UTXOPool) FindOutput(txID []byte, outputIndex int) (TXOutput, bool) {
+	key := string(txID)
+	for _, byTx := range pool.VerifiedTransactions {
+		if outputs, ok := byTx[key]; ok {
+			if out, ok := outputs[outputIndex]; ok {
+				return out, true
+			}
+		}
+	}
+	return TXOutput{}, false
+}
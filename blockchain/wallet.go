@@ -0,0 +1,32 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+)
+
+// Wallet holds an ECDSA keypair and the address derived from it, so that txgen's fake test
+// addresses can actually sign the inputs they spend instead of leaving ScriptSig empty.
+type Wallet struct {
+	PrivateKey ecdsa.PrivateKey
+	PublicKey  []byte
+}
+
+// NewWallet generates a fresh P-256 keypair and the address it controls.
+func NewWallet() (*Wallet, error) {
+	curve := elliptic.P256()
+
+	private, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	public := append(paddedBytes(private.PublicKey.X), paddedBytes(private.PublicKey.Y)...)
+
+	return &Wallet{PrivateKey: *private, PublicKey: public}, nil
+}
+
+// Address returns the base58check-encoded address controlling this wallet's outputs.
+func (w *Wallet) Address() string {
+	return AddressFromPubKeyHash(HashPubKey(w.PublicKey))
+}
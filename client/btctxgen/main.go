@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"harmony-benchmark/blockchain"
@@ -12,6 +13,7 @@ import (
 	"harmony-benchmark/node"
 	"harmony-benchmark/p2p"
 	proto_node "harmony-benchmark/proto/node"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -21,21 +23,46 @@ import (
 type txGenSettings struct {
 	crossShard        bool
 	maxNumTxsPerBatch int
+	feeRate           int
+	blockBytesBudget  int
 }
 
 var (
 	utxoPoolMutex sync.Mutex
 	setting       txGenSettings
-	btcTXIter     btctxiter.BTCTXIterator
+	btcTXIter     btctxiter.TxSource
+
+	// shardFeeRevenue accumulates the fees generated txs have paid on each shard, so it can
+	// be logged per batch to benchmark throughput under different fee-market regimes.
+	feeRevenueMutex sync.Mutex
+	shardFeeRevenue = make(map[int]int)
 )
 
+// newTxSource builds the configured TxSource: "file" replays a local bootstrap.dat-style
+// file, "rpc" pulls blocks from a running btcd/bitcoind node (optionally mining new blocks on
+// demand in regtest mode).
+func newTxSource(txSource, bootstrapFile, rpcEndpoint, rpcUser, rpcPassword string, rpcRegtest bool, rpcGenerateBlocks int, rpcGenerateToAddress string) btctxiter.TxSource {
+	switch txSource {
+	case "rpc":
+		return btctxiter.NewRPCTxSource(rpcEndpoint, rpcUser, rpcPassword, rpcRegtest, rpcGenerateBlocks, rpcGenerateToAddress)
+	default:
+		return btctxiter.NewBTCTXIterator(bootstrapFile)
+	}
+}
+
 // Generates at most "maxNumTxs" number of simulated transactions based on the current UtxoPools of all shards.
 // The transactions are generated by going through the existing utxos and
 // randomly select a subset of them as the input for each new transaction. The output
 // address of the new transaction are randomly selected from [0 - N), where N is the total number of fake addresses.
 //
 // When crossShard=true, besides the selected utxo input, select another valid utxo as input from the same address in a second shard.
-// Similarly, generate another utxo output in that second shard.
+// Similarly, generate another utxo output in that second shard. The second utxo is picked from
+// dataNodes[otherShardID].UtxoPool, i.e. the same address must actually hold spendable funds in
+// the other shard; we don't fabricate cross-shard inputs out of thin air.
+//
+// The batch also stops early, before maxNumTxsPerBatch, once the estimated serialized size of
+// the txs generated so far would exceed setting.blockBytesBudget -- a fixed tx count doesn't
+// account for the fact that bigger (more inputs/outputs) txs eat more of a shard's block space.
 //
 // NOTE: the genesis block should contain N coinbase transactions which add
 //       token (1000) to each address in [0 - N). See node.AddTestingAddresses()
@@ -43,10 +70,12 @@ var (
 // Params:
 //     shardID                    - the shardID for current shard
 //     dataNodes                  - nodes containing utxopools of all shards
+//     spentInBatch               - utxos already claimed by this tick's earlier calls (shared
+//                                  across every shard's call in the same tick, not just this one)
 // Returns:
 //     all single-shard txs
 //     all cross-shard txs
-func generateSimulatedTransactions(shardID int, dataNodes []*node.Node) ([]*blockchain.Transaction, []*blockchain.Transaction) {
+func generateSimulatedTransactions(shardID int, dataNodes []*node.Node, spentInBatch map[string]bool) ([]*blockchain.Transaction, []*blockchain.Transaction) {
 	/*
 		  UTXO map structure:
 		  {
@@ -59,11 +88,14 @@ func generateSimulatedTransactions(shardID int, dataNodes []*node.Node) ([]*bloc
 	*/
 
 	utxoPoolMutex.Lock()
+	defer utxoPoolMutex.Unlock()
+
 	txs := []*blockchain.Transaction{}
 	crossTxs := []*blockchain.Transaction{}
 
 	nodeShardID := dataNodes[shardID].Consensus.ShardID
 	cnt := 0
+	batchBytes := 0
 
 LOOP:
 	for true {
@@ -74,36 +106,277 @@ LOOP:
 			// TxIn coinbase, newly generated coins
 			prevTxID := [32]byte{}
 			// TODO: merge txID with txIndex in TxInput
-			tx.TxInput = []blockchain.TXInput{blockchain.TXInput{prevTxID, -1, "", nodeShardID}}
+			tx.TxInput = []blockchain.TXInput{{TxID: prevTxID, TxOutIndex: -1, ShardID: nodeShardID}}
 		} else {
 			for _, txi := range btcTx.TxIn {
-				tx.TxInput = append(tx.TxInput, blockchain.TXInput{txi.Input.Hash, int(txi.Input.Vout), "", nodeShardID})
+				tx.TxInput = append(tx.TxInput, blockchain.TXInput{TxID: txi.Input.Hash, TxOutIndex: int(txi.Input.Vout), ShardID: nodeShardID})
 			}
 		}
 
+		var outputAddr string
 		for _, txo := range btcTx.TxOut {
 			txoAddr := btc.NewAddrFromPkScript(txo.Pk_script, false)
 			if txoAddr == nil {
 				log.Warn("TxOut: can't decode address")
+				continue
 			}
-			txout := blockchain.TXOutput{int(txo.Value), txoAddr.String(), nodeShardID}
+			outputAddr = txoAddr.String()
+			txout := blockchain.NewTXOutput(int(txo.Value), outputAddr, nodeShardID)
 			tx.TxOutput = append(tx.TxOutput, txout)
 		}
+
+		txSize := blockchain.EstimateSize(len(tx.TxInput), len(tx.TxOutput))
+
+		// A single-shard tx's inputs are replayed straight off a real Bitcoin tx rather than
+		// selected from our own UtxoPool, so there's no utxo to pick for dust-minimization the
+		// way pickUnspentUtxo does for cross-shard txs -- but it can still pay a fee like any
+		// other tx, taken out of its last output.
+		if !btcTx.IsCoinBase() && len(tx.TxOutput) > 0 {
+			fee := blockchain.EstimateFee(txSize, setting.feeRate)
+			last := len(tx.TxOutput) - 1
+			if tx.TxOutput[last].Value > fee {
+				tx.TxOutput[last].Value -= fee
+				recordFeeRevenue(nodeShardID, fee)
+			}
+		}
+
+		if !btcTx.IsCoinBase() && outputAddr != "" {
+			signSingleShardTx(&tx, outputAddr)
+		}
 		tx.SetID()
+
+		if batchBytes > 0 && batchBytes+txSize > setting.blockBytesBudget {
+			log.Debug("[Generator] shrinking batch, block bytes budget reached", "shardId", nodeShardID, "batchBytes", batchBytes, "numTxs", cnt)
+			break LOOP
+		}
+		batchBytes += txSize
+
 		txs = append(txs, &tx)
 		// log.Debug("[Generator] transformed btc tx", "block height", btcTXIter.GetBlockIndex(), "block tx count", btcTXIter.GetBlock().TxCount, "block tx cnt", len(btcTXIter.GetBlock().Txs), "txi", len(tx.TxInput), "txo", len(tx.TxOutput), "txCount", cnt)
 		cnt++
+
+		if setting.crossShard {
+			if address, ok := randomTestAddress(); ok {
+				if crossTx := generateCrossShardTx(shardID, dataNodes, address, spentInBatch); crossTx != nil {
+					crossTxs = append(crossTxs, crossTx)
+					batchBytes += blockchain.EstimateSize(len(crossTx.TxInput), len(crossTx.TxOutput))
+				}
+			}
+		}
+
 		if cnt >= setting.maxNumTxsPerBatch {
 			break LOOP
 		}
 	}
 
-	utxoPoolMutex.Unlock()
-
 	log.Debug("[Generator] generated transations", "single-shard", len(txs), "cross-shard", len(crossTxs))
 	return txs, crossTxs
 }
 
+// generateCrossShardTx looks for a shard other than shardID where address also holds a
+// spendable utxo, and if one exists builds a transaction spending one utxo from each shard
+// and paying address back on both sides. spentInBatch is checked and updated so that a utxo
+// claimed by one cross-shard tx cannot be claimed again later in the same batch. Returns nil
+// if address has no matching cross-shard utxo to pair with.
+func generateCrossShardTx(shardID int, dataNodes []*node.Node, address string, spentInBatch map[string]bool) *blockchain.Transaction {
+	// A cross-shard tx always has 2 inputs and 2 outputs; estimate its fee up front so utxo
+	// selection can take it into account rather than risking a negative change output.
+	fee := blockchain.EstimateFee(blockchain.EstimateSize(2, 2), setting.feeRate)
+
+	srcNode := dataNodes[shardID]
+	srcUtxo, ok := pickUnspentUtxo(srcNode, address, spentInBatch, fee)
+	if !ok {
+		return nil
+	}
+
+	for otherShardID, dstNode := range dataNodes {
+		if otherShardID == shardID {
+			continue
+		}
+		dstUtxo, ok := pickUnspentUtxo(dstNode, address, spentInBatch, 0)
+		if !ok {
+			continue
+		}
+
+		markSpentInBatch(spentInBatch, address, srcUtxo)
+		markSpentInBatch(spentInBatch, address, dstUtxo)
+
+		srcShardID := srcNode.Consensus.ShardID
+		dstShardID := dstNode.Consensus.ShardID
+
+		recordFeeRevenue(srcShardID, fee)
+
+		tx := blockchain.Transaction{
+			TxInput: []blockchain.TXInput{
+				{TxID: txIDToArray(srcUtxo.TxID), TxOutIndex: srcUtxo.Index, ShardID: srcShardID},
+				{TxID: txIDToArray(dstUtxo.TxID), TxOutIndex: dstUtxo.Index, ShardID: dstShardID},
+			},
+			TxOutput: []blockchain.TXOutput{
+				// The fee is taken out of the source side's change output.
+				blockchain.NewTXOutput(srcUtxo.TXOutput.Value-fee, address, srcShardID),
+				blockchain.NewTXOutput(dstUtxo.TXOutput.Value, address, dstShardID),
+			},
+		}
+
+		if wallet, ok := node.TestWallet(address); ok {
+			prevTXs := map[string]blockchain.Transaction{
+				prevTxKey(srcUtxo.TxID): stubPrevTx(srcUtxo),
+				prevTxKey(dstUtxo.TxID): stubPrevTx(dstUtxo),
+			}
+			tx.Sign(wallet.PrivateKey, prevTXs)
+		} else {
+			log.Warn("[Generator] no test wallet for cross-shard sender, leaving inputs unsigned", "address", address)
+		}
+
+		tx.SetID()
+		return &tx
+	}
+
+	return nil
+}
+
+// prevTxKey is the hex-encoded transaction ID a cross-shard input's prevTXs entry is keyed
+// by, matching blockchain.Transaction.Sign/Verify.
+func prevTxKey(txID []byte) string {
+	return hex.EncodeToString(txID)
+}
+
+// randomTestAddress picks one of the fake addresses node.AddTestingAddresses minted, credited
+// with funds on every shard -- the only addresses generateCrossShardTx can actually find a
+// matching utxo for, since pickUnspentUtxo only ever hits on an address already present in a
+// node's UtxoPool. A real address decoded off a replayed Bitcoin tx never is.
+func randomTestAddress() (string, bool) {
+	addresses := node.TestAddresses()
+	if len(addresses) == 0 {
+		return "", false
+	}
+	return addresses[rand.Intn(len(addresses))], true
+}
+
+// signSingleShardTx signs tx's inputs with the wallet controlling outputAddr, if we have one.
+// A single-shard tx's inputs are replayed straight off a real historical Bitcoin tx, so we
+// hold no record locally of the output they actually reference; for signing purposes each
+// input's previous output is treated as if it too paid outputAddr, i.e. the same fake address
+// is both sender and receiver in this simulation.
+func signSingleShardTx(tx *blockchain.Transaction, outputAddr string) {
+	wallet, ok := node.TestWallet(outputAddr)
+	if !ok {
+		log.Warn("[Generator] no test wallet for single-shard sender, leaving inputs unsigned", "address", outputAddr)
+		return
+	}
+
+	prevTXs := make(map[string]blockchain.Transaction)
+	for _, in := range tx.TxInput {
+		outputs := make([]blockchain.TXOutput, in.TxOutIndex+1)
+		outputs[in.TxOutIndex] = blockchain.NewTXOutput(0, outputAddr, in.ShardID)
+		prevTXs[prevTxKey(in.TxID[:])] = blockchain.Transaction{TxOutput: outputs}
+	}
+	tx.Sign(wallet.PrivateKey, prevTXs)
+}
+
+// stubPrevTx builds the minimal Transaction Sign/Verify need to look up the TXOutput a
+// SpendableOutput refers to: a TxOutput slice padded out to utxo.Index so
+// prevTX.TxOutput[utxo.Index] resolves to the real output being spent.
+func stubPrevTx(utxo blockchain.SpendableOutput) blockchain.Transaction {
+	outputs := make([]blockchain.TXOutput, utxo.Index+1)
+	outputs[utxo.Index] = utxo.TXOutput
+	return blockchain.Transaction{TxOutput: outputs}
+}
+
+// pickUnspentUtxo returns the utxo owned by address in n's pool, not already claimed earlier
+// in this batch, that covers minValue with the least leftover change -- minimizing the dust
+// left behind in the change output.
+func pickUnspentUtxo(n *node.Node, address string, spentInBatch map[string]bool, minValue int) (blockchain.SpendableOutput, bool) {
+	var best blockchain.SpendableOutput
+	found := false
+
+	for _, utxo := range n.UtxoPool.SpendableOutputsForAddress(address) {
+		if spentInBatch[utxoBatchKey(address, utxo)] {
+			continue
+		}
+		if utxo.TXOutput.Value < minValue {
+			continue
+		}
+		if !found || utxo.TXOutput.Value < best.TXOutput.Value {
+			best = utxo
+			found = true
+		}
+	}
+
+	return best, found
+}
+
+// recordFeeRevenue adds fee to shardID's running total, logged per batch so txgen.log
+// captures throughput under whatever fee-market regime --fee_rate was run with.
+func recordFeeRevenue(shardID int, fee int) {
+	feeRevenueMutex.Lock()
+	shardFeeRevenue[shardID] += fee
+	feeRevenueMutex.Unlock()
+}
+
+// logFeeRevenue writes each shard's cumulative fee revenue so far to txgen.log.
+func logFeeRevenue() {
+	feeRevenueMutex.Lock()
+	defer feeRevenueMutex.Unlock()
+	for shardID, fee := range shardFeeRevenue {
+		log.Debug("[Generator] cumulative fee revenue", "shardId", shardID, "fee", fee, "feeRate", setting.feeRate)
+	}
+}
+
+func markSpentInBatch(spentInBatch map[string]bool, address string, utxo blockchain.SpendableOutput) {
+	spentInBatch[utxoBatchKey(address, utxo)] = true
+}
+
+func utxoBatchKey(address string, utxo blockchain.SpendableOutput) string {
+	return fmt.Sprintf("%s:%x:%d", address, utxo.TxID, utxo.Index)
+}
+
+func txIDToArray(txID []byte) [32]byte {
+	var id [32]byte
+	copy(id[:], txID)
+	return id
+}
+
+// verifyBlockTransactions checks every non-coinbase transaction in block we can actually verify
+// against n's current UtxoPool (looking up each input's referenced output across every address,
+// since we don't know in advance which one it belongs to) -- the same check a leader must make
+// before including a transaction in a block. A client applying an already-mined block is the
+// next best place to make this check in this codebase, mirroring the Merkle-root check right
+// above it.
+//
+// A single-shard tx's inputs are replayed straight off real Bitcoin history and were never
+// minted into our UtxoPool, so we have nothing to resolve them against; such a tx is skipped
+// rather than rejected. Only a tx whose inputs *do* resolve -- in practice, our own cross-shard
+// txs -- is actually held to Verify, so one unresolvable input doesn't drop an entire block.
+func verifyBlockTransactions(n *node.Node, block *blockchain.Block) bool {
+	for _, tx := range block.Transactions {
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		prevTXs := make(map[string]blockchain.Transaction)
+		resolvable := true
+		for _, in := range tx.TxInput {
+			out, ok := n.UtxoPool.FindOutput(in.TxID[:], in.TxOutIndex)
+			if !ok {
+				resolvable = false
+				break
+			}
+			outputs := make([]blockchain.TXOutput, in.TxOutIndex+1)
+			outputs[in.TxOutIndex] = out
+			prevTXs[prevTxKey(in.TxID[:])] = blockchain.Transaction{TxOutput: outputs}
+		}
+		if !resolvable {
+			continue
+		}
+
+		if !tx.Verify(prevTXs) {
+			return false
+		}
+	}
+	return true
+}
+
 func initClient(clientNode *node.Node, clientPort string, leaders *[]p2p.Peer, nodes *[]*node.Node) {
 	if clientPort == "" {
 		return
@@ -115,8 +388,18 @@ func initClient(clientNode *node.Node, clientPort string, leaders *[]p2p.Peer, n
 	updateBlocksFunc := func(blocks []*blockchain.Block) {
 		log.Debug("Received new block from leader", "len", len(blocks))
 		for _, block := range blocks {
+			// Verify the block's Merkle root against its own tx list rather than trusting
+			// whatever the leader sent us.
+			if !blockchain.VerifyMerkleRoot(block) {
+				log.Warn("Dropping block with invalid Merkle root", "shardId", block.ShardId)
+				continue
+			}
 			for _, node := range *nodes {
 				if node.Consensus.ShardID == block.ShardId {
+					if !verifyBlockTransactions(node, block) {
+						log.Warn("Dropping block with an invalid transaction signature", "shardId", block.ShardId)
+						continue
+					}
 					log.Debug("Adding block from leader", "shardId", block.ShardId)
 					// Add it to blockchain
 					utxoPoolMutex.Lock()
@@ -126,10 +409,29 @@ func initClient(clientNode *node.Node, clientPort string, leaders *[]p2p.Peer, n
 					continue
 				}
 			}
+
+			// A mined tx is no longer merely pending -- drop it from the mempool view.
+			clientNode.Client.PendingMempoolMutex.Lock()
+			for _, tx := range block.Transactions {
+				delete(clientNode.Client.PendingMempoolTxs, hex.EncodeToString(tx.ID))
+			}
+			clientNode.Client.PendingMempoolMutex.Unlock()
 		}
 	}
 	clientNode.Client.UpdateBlocks = updateBlocksFunc
 
+	// This func tracks txs leaders have announced into their mempools, ahead of being mined,
+	// so announce-to-include latency can be measured alongside block-level latency.
+	updateMempoolFunc := func(txs []*blockchain.Transaction) {
+		log.Debug("Received mempool announcement from leader", "numTxs", len(txs))
+		clientNode.Client.PendingMempoolMutex.Lock()
+		for _, tx := range txs {
+			clientNode.Client.PendingMempoolTxs[hex.EncodeToString(tx.ID)] = tx
+		}
+		clientNode.Client.PendingMempoolMutex.Unlock()
+	}
+	clientNode.Client.UpdateMempool = updateMempoolFunc
+
 	// Start the client server to listen to leader's message
 	go func() {
 		clientNode.StartServer(clientPort)
@@ -140,6 +442,17 @@ func main() {
 	configFile := flag.String("config_file", "local_config.txt", "file containing all ip addresses and config")
 	maxNumTxsPerBatch := flag.Int("max_num_txs_per_batch", 100, "number of transactions to send per message")
 	logFolder := flag.String("log_folder", "latest", "the folder collecting the logs of this execution")
+	txSource := flag.String("tx_source", "file", "where to pull transactions to replay from: file or rpc")
+	bootstrapFile := flag.String("bootstrap_file", "bootstrap.dat", "bootstrap.dat-style block file to replay when tx_source=file")
+	rpcEndpoint := flag.String("rpc_endpoint", "http://127.0.0.1:18443", "JSON-RPC endpoint of the btcd/bitcoind node to pull blocks from when tx_source=rpc")
+	rpcUser := flag.String("rpc_user", "", "JSON-RPC username when tx_source=rpc")
+	rpcPassword := flag.String("rpc_password", "", "JSON-RPC password when tx_source=rpc")
+	rpcRegtest := flag.Bool("rpc_regtest", false, "when tx_source=rpc, mine new blocks on demand via generatetoaddress instead of only replaying existing history")
+	rpcGenerateBlocks := flag.Int("rpc_generate_blocks", 1, "number of blocks to mine per generatetoaddress call when rpc_regtest is set")
+	rpcGenerateToAddress := flag.String("rpc_generate_to_address", "", "regtest address to mine rewards to when rpc_regtest is set")
+	numTestingAddresses := flag.Int("num_testing_addresses", 10, "number of fake funded addresses to credit on every shard, shared so cross-shard txs can pair them")
+	feeRate := flag.Int("fee_rate", 1, "fee, in value units per estimated serialized byte, charged on generated single-shard and cross-shard txs")
+	blockBytesBudget := flag.Int("block_bytes_budget", 1000000, "per-shard serialized-bytes budget per batch; the batch shrinks below max_num_txs_per_batch once generated txs would exceed it")
 	flag.Parse()
 
 	// Read the configs
@@ -149,6 +462,8 @@ func main() {
 	// Do cross shard tx if there are more than one shard
 	setting.crossShard = len(shardIDs) > 1
 	setting.maxNumTxsPerBatch = *maxNumTxsPerBatch
+	setting.feeRate = *feeRate
+	setting.blockBytesBudget = *blockBytesBudget
 
 	// TODO(Richard): refactor this chuck to a single method
 	// Setup a logger to stdout and log file.
@@ -160,12 +475,20 @@ func main() {
 	)
 	log.Root().SetHandler(h)
 
-	btcTXIter.Init()
+	btcTXIter = newTxSource(*txSource, *bootstrapFile, *rpcEndpoint, *rpcUser, *rpcPassword, *rpcRegtest, *rpcGenerateBlocks, *rpcGenerateToAddress)
+	if err := btcTXIter.Init(); err != nil {
+		log.Error("failed to initialize tx source", "tx_source", *txSource, "err", err)
+		return
+	}
 
 	// Nodes containing utxopools to mirror the shards' data in the network
 	nodes := []*node.Node{}
 	for _, shardID := range shardIDs {
-		nodes = append(nodes, node.New(&consensus.Consensus{ShardID: shardID}))
+		n := node.New(&consensus.Consensus{ShardID: shardID})
+		// Credit the same fake addresses on every shard so a cross-shard tx can find one
+		// that holds funds in more than one shard to pair.
+		n.AddTestingAddresses(*numTestingAddresses)
+		nodes = append(nodes, n)
 	}
 
 	// Client/txgenerator server node setup
@@ -188,9 +511,12 @@ func main() {
 		}
 
 		allCrossTxs := []*blockchain.Transaction{}
+		// spentInBatch is shared across every shard's call below so two shards can't both pick
+		// the same still-unmined utxo as an input in the same tick.
+		spentInBatch := make(map[string]bool)
 		// Generate simulated transactions
 		for i, leader := range leaders {
-			txs, crossTxs := generateSimulatedTransactions(i, nodes)
+			txs, crossTxs := generateSimulatedTransactions(i, nodes, spentInBatch)
 			allCrossTxs = append(allCrossTxs, crossTxs...)
 
 			log.Debug("[Generator] Sending single-shard txs ...", "leader", leader, "numTxs", len(txs), "numCrossTxs", len(crossTxs))
@@ -208,12 +534,14 @@ func main() {
 			if clientPort != "" {
 				clientNode.Client.PendingCrossTxsMutex.Lock()
 				for _, tx := range allCrossTxs {
-					clientNode.Client.PendingCrossTxs[tx.ID] = tx
+					clientNode.Client.PendingCrossTxs[hex.EncodeToString(tx.ID)] = tx
 				}
 				clientNode.Client.PendingCrossTxsMutex.Unlock()
 			}
 		}
 
+		logFeeRevenue()
+
 		time.Sleep(500 * time.Millisecond) // Send a batch of transactions periodically
 	}
 
@@ -221,4 +549,4 @@ func main() {
 	msg := proto_node.ConstructStopMessage()
 	peers := append(configr.GetValidators(*configFile), leaders...)
 	p2p.BroadcastMessage(peers, msg)
-}
\ No newline at end of file
+}
@@ -0,0 +1,117 @@
+package btctxiter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"harmony-benchmark/log"
+
+	"github.com/piotrnar/gocoin/lib/btc"
+)
+
+// rewindRetryBackoff is how long NextTx waits, after a failed block load and the rewind that
+// follows it, before retrying, so a malformed bootstrap file spins at a bounded rate instead of busy-looping.
+const rewindRetryBackoff = time.Second
+
+// bootstrapMagic is the 4-byte magic gocoin-style bootstrap.dat files prefix each block with,
+// followed by a little-endian uint32 block length and then the raw block bytes.
+var bootstrapMagic = [4]byte{0xf9, 0xbe, 0xb4, 0xd9}
+
+// BTCTXIterator is the original TxSource: it replays transactions out of a local
+// bootstrap.dat-style block file, looping back to the start once it reaches EOF so a run can
+// ask for more transactions than the file contains.
+type BTCTXIterator struct {
+	BootstrapFile string
+
+	file       *os.File
+	reader     *bufio.Reader
+	blockIndex int
+	block      *btc.Block
+	blockTxIdx int
+}
+
+// NewBTCTXIterator creates a file-backed TxSource reading from bootstrapFile.
+func NewBTCTXIterator(bootstrapFile string) *BTCTXIterator {
+	return &BTCTXIterator{BootstrapFile: bootstrapFile}
+}
+
+// Init opens the bootstrap file and loads the first block.
+func (it *BTCTXIterator) Init() error {
+	file, err := os.Open(it.BootstrapFile)
+	if err != nil {
+		return err
+	}
+	it.file = file
+	it.reader = bufio.NewReader(file)
+	return it.loadNextBlock()
+}
+
+// GetBlockIndex returns the index (within the file) of the block NextTx is currently serving
+// transactions from.
+func (it *BTCTXIterator) GetBlockIndex() int {
+	return it.blockIndex
+}
+
+// GetBlock returns the block NextTx is currently serving transactions from.
+func (it *BTCTXIterator) GetBlock() *btc.Block {
+	return it.block
+}
+
+// NextTx returns the next transaction in file order, advancing to the next block (or looping
+// back to the start of the file) as each block is exhausted.
+func (it *BTCTXIterator) NextTx() *btc.Tx {
+	for it.block == nil || it.blockTxIdx >= len(it.block.Txs) {
+		if err := it.loadNextBlock(); err != nil {
+			log.Error("btctxiter: failed to load next block, rewinding to start of file", "err", err)
+			it.rewind()
+			time.Sleep(rewindRetryBackoff)
+		}
+	}
+	tx := it.block.Txs[it.blockTxIdx]
+	it.blockTxIdx++
+	return tx
+}
+
+// loadNextBlock reads the next length-prefixed block off the file, parsing it into a
+// *btc.Block and resetting the per-block transaction cursor.
+func (it *BTCTXIterator) loadNextBlock() error {
+	var magic [4]byte
+	if _, err := io.ReadFull(it.reader, magic[:]); err != nil {
+		return err
+	}
+
+	var length uint32
+	if err := binary.Read(it.reader, binary.LittleEndian, &length); err != nil {
+		return err
+	}
+
+	raw := make([]byte, length)
+	if _, err := io.ReadFull(it.reader, raw); err != nil {
+		return err
+	}
+
+	block, err := btc.NewBlock(raw)
+	if err != nil {
+		return err
+	}
+	if err := block.BuildTxList(); err != nil {
+		return err
+	}
+
+	it.block = block
+	it.blockIndex++
+	it.blockTxIdx = 0
+	return nil
+}
+
+// rewind seeks back to the start of the bootstrap file so NextTx can keep serving
+// transactions once the file has been fully replayed.
+func (it *BTCTXIterator) rewind() {
+	it.file.Seek(0, io.SeekStart)
+	it.reader = bufio.NewReader(it.file)
+	it.block = nil
+	it.blockIndex = 0
+}
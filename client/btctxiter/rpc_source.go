@@ -0,0 +1,170 @@
+package btctxiter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"harmony-benchmark/log"
+
+	"github.com/piotrnar/gocoin/lib/btc"
+)
+
+// retryBackoff is how long NextTx waits after a failed fetch before trying again, so a
+// persistent RPC error -- including, outside regtest, simply having caught up to the chain
+// tip, where getblockhash for a future height errors forever -- spins at a bounded rate
+// instead of hammering the endpoint.
+const retryBackoff = 2 * time.Second
+
+// RPCTxSource pulls transactions from a running btcd/bitcoind node over JSON-RPC instead of a
+// static bootstrap file, so a run can be driven off a live chain. In Regtest mode it mines
+// GenerateBlocks blocks (via generatetoaddress) whenever it runs out of buffered transactions,
+// synthesizing load on demand rather than being limited to whatever history the node already
+// has.
+type RPCTxSource struct {
+	Endpoint string
+	User     string
+	Password string
+
+	Regtest           bool
+	GenerateBlocks    int
+	GenerateToAddress string
+
+	httpClient *http.Client
+	nextHeight int
+	rpcID      int
+	pendingTxs []*btc.Tx
+}
+
+// NewRPCTxSource creates an RPC-backed TxSource talking to the node at endpoint.
+func NewRPCTxSource(endpoint, user, password string, regtest bool, generateBlocks int, generateToAddress string) *RPCTxSource {
+	return &RPCTxSource{
+		Endpoint:          endpoint,
+		User:              user,
+		Password:          password,
+		Regtest:           regtest,
+		GenerateBlocks:    generateBlocks,
+		GenerateToAddress: generateToAddress,
+	}
+}
+
+// Init dials the RPC endpoint and starts replay from the node's current chain tip.
+func (it *RPCTxSource) Init() error {
+	it.httpClient = &http.Client{}
+
+	var height int
+	if err := it.call("getblockcount", nil, &height); err != nil {
+		return err
+	}
+	it.nextHeight = height
+	return nil
+}
+
+// NextTx returns the next transaction, fetching (and, in regtest mode, mining) further blocks
+// as needed to keep the buffer non-empty.
+func (it *RPCTxSource) NextTx() *btc.Tx {
+	for len(it.pendingTxs) == 0 {
+		if err := it.fillFromNextBlock(); err != nil {
+			log.Error("btctxiter: rpc source failed to fetch next block", "err", err)
+			time.Sleep(retryBackoff)
+		}
+	}
+	tx := it.pendingTxs[0]
+	it.pendingTxs = it.pendingTxs[1:]
+	return tx
+}
+
+// fillFromNextBlock fetches (generating one first, in regtest mode) the block at nextHeight
+// and buffers its transactions.
+func (it *RPCTxSource) fillFromNextBlock() error {
+	if it.Regtest {
+		var generated []string
+		if err := it.call("generatetoaddress", []interface{}{it.GenerateBlocks, it.GenerateToAddress}, &generated); err != nil {
+			return err
+		}
+	}
+
+	var blockHash string
+	if err := it.call("getblockhash", []interface{}{it.nextHeight}, &blockHash); err != nil {
+		return err
+	}
+
+	var blockVerbose struct {
+		Tx []struct {
+			Hex string `json:"hex"`
+		} `json:"tx"`
+	}
+	if err := it.call("getblock", []interface{}{blockHash, 2}, &blockVerbose); err != nil {
+		return err
+	}
+
+	for _, rawTx := range blockVerbose.Tx {
+		data, err := hex.DecodeString(rawTx.Hex)
+		if err != nil {
+			log.Warn("btctxiter: can't decode raw tx hex", "err", err)
+			continue
+		}
+		tx, _ := btc.NewTx(data)
+		if tx != nil {
+			it.pendingTxs = append(it.pendingTxs, tx)
+		}
+	}
+
+	it.nextHeight++
+	return nil
+}
+
+// call issues a JSON-RPC 1.0 request for method with the given params and decodes the result
+// into out.
+func (it *RPCTxSource) call(method string, params []interface{}, out interface{}) error {
+	it.rpcID++
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      it.rpcID,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", it.Endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(it.User, it.Password)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := it.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &rpcResp); err != nil {
+		return err
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("btctxiter: rpc error calling %s: %s", method, rpcResp.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(rpcResp.Result, out)
+}
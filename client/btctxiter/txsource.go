@@ -0,0 +1,17 @@
+// Package btctxiter supplies txgen with a stream of real Bitcoin transactions to replay as
+// simulated Harmony transactions, either from a local chain file or from a live btcd/bitcoind
+// node, so benchmark runs aren't limited to whatever bootstrap.dat snapshot is on disk.
+package btctxiter
+
+import "github.com/piotrnar/gocoin/lib/btc"
+
+// TxSource produces a continuous stream of Bitcoin transactions. NextTx blocks until the next
+// transaction is available; implementations loop or generate new blocks rather than ever
+// signalling end-of-stream, since txgen runs for a fixed duration rather than a fixed tx count.
+type TxSource interface {
+	// Init prepares the source (opens the bootstrap file, or dials the RPC endpoint) and must
+	// be called once before the first NextTx.
+	Init() error
+	// NextTx returns the next transaction in source order.
+	NextTx() *btc.Tx
+}
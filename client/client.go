@@ -0,0 +1,44 @@
+// Package client implements the light client txgen runs alongside itself: it doesn't hold a
+// shard's full UTXO pool, but tracks the cross-shard txs it's waiting on proofs for and the
+// leaders' mempools it's waiting on inclusion for.
+package client
+
+import (
+	"sync"
+
+	"harmony-benchmark/blockchain"
+	"harmony-benchmark/p2p"
+)
+
+// Client is attached to a Node acting as a light client of one or more shards' leaders.
+type Client struct {
+	leaders *[]p2p.Peer
+
+	// UpdateBlocks is invoked with newly received blocks from a leader.
+	UpdateBlocks func(blocks []*blockchain.Block)
+
+	// PendingCrossTxs holds cross-shard txs sent out but not yet confirmed by a matching
+	// proof from every shard involved, keyed by hex transaction ID.
+	PendingCrossTxs      map[string]*blockchain.Transaction
+	PendingCrossTxsMutex sync.Mutex
+
+	// UpdateMempool is invoked with txs a leader has just announced into its mempool, ahead
+	// of them being mined into a block.
+	UpdateMempool func(txs []*blockchain.Transaction)
+
+	// PendingMempoolTxs mirrors the union of leaders' mempools as seen through
+	// MempoolAnnounce messages, keyed by hex transaction ID, so propagation latency
+	// (announce -> include) can be measured rather than only block-level latency. Entries
+	// are evicted once the tx is seen mined into a block.
+	PendingMempoolTxs   map[string]*blockchain.Transaction
+	PendingMempoolMutex sync.Mutex
+}
+
+// NewClient creates a Client tracking leaders.
+func NewClient(leaders *[]p2p.Peer) *Client {
+	return &Client{
+		leaders:           leaders,
+		PendingCrossTxs:   make(map[string]*blockchain.Transaction),
+		PendingMempoolTxs: make(map[string]*blockchain.Transaction),
+	}
+}
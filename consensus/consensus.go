@@ -0,0 +1,7 @@
+package consensus
+
+// Consensus holds the state a node needs to participate in (or, for the
+// txgen client, mirror) a single shard's PBFT-style consensus.
+type Consensus struct {
+	ShardID int
+}
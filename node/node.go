@@ -0,0 +1,154 @@
+package node
+
+import (
+	"encoding/hex"
+
+	"harmony-benchmark/blockchain"
+	"harmony-benchmark/client"
+	"harmony-benchmark/consensus"
+	"harmony-benchmark/p2p"
+	proto_node "harmony-benchmark/proto/node"
+)
+
+// Node represents a single participant in a shard: its consensus context,
+// its view of that shard's spendable outputs, and (for the txgen/client
+// process) the light client attached to it.
+type Node struct {
+	Consensus *consensus.Consensus
+	UtxoPool  *blockchain.UTXOPool
+	Client    *client.Client
+
+	// ClientPeers is who a leader announces newly accepted mempool txs to; unset for a Node
+	// that only ever acts as a client itself.
+	ClientPeers []p2p.Peer
+}
+
+// New creates a Node for the given consensus context with a fresh, empty
+// UTXO pool.
+func New(consensus *consensus.Consensus) *Node {
+	return &Node{
+		Consensus: consensus,
+		UtxoPool:  blockchain.NewUTXOPool(),
+	}
+}
+
+// sharedTestWallets is the per-fake-address keypair table every shard's Node credits the same
+// N fake addresses from, so a cross-shard tx generated for one of them has a real private key
+// to sign with regardless of which shard's Node is doing the signing.
+var sharedTestWallets = make(map[string]*blockchain.Wallet)
+
+// AddTestingAddresses credits node's UtxoPool with a coinbase subsidy for each of numAddresses
+// fake addresses. The first Node to call this mints the addresses (and their keypairs, into
+// the shared table returned by TestWallet); every subsequent Node (one per shard) just credits
+// the same addresses, which is what lets generateSimulatedTransactions find a single address
+// holding funds in more than one shard.
+func (node *Node) AddTestingAddresses(numAddresses int) {
+	if len(sharedTestWallets) == 0 {
+		for i := 0; i < numAddresses; i++ {
+			wallet, err := blockchain.NewWallet()
+			if err != nil {
+				continue
+			}
+			sharedTestWallets[wallet.Address()] = wallet
+		}
+	}
+
+	for address := range sharedTestWallets {
+		cbtx, _ := blockchain.NewCoinbaseTX(address, "")
+		node.UtxoPool.AddOutput(address, cbtx.ID, 0, cbtx.TxOutput[0])
+	}
+}
+
+// TestWallet returns the keypair backing a fake address minted by AddTestingAddresses.
+func TestWallet(address string) (*blockchain.Wallet, bool) {
+	wallet, ok := sharedTestWallets[address]
+	return wallet, ok
+}
+
+// TestAddresses returns every fake address minted by AddTestingAddresses, credited with funds
+// on every shard. Callers that need a locally-controlled address to generate a transaction with
+// (rather than one decoded off replayed Bitcoin history, which we hold no keypair for) should
+// pick from this list.
+func TestAddresses() []string {
+	addresses := make([]string, 0, len(sharedTestWallets))
+	for address := range sharedTestWallets {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// StartServer starts node's p2p listener on port, accepting connections from leaders/clients
+// until the process exits.
+func (node *Node) StartServer(port string) {
+	p2p.Listen(port, node.handleMessage)
+}
+
+// handleMessage dispatches a decoded wire message to the right part of node's state. Block
+// delivery is wired up separately through Client.UpdateBlocks.
+func (node *Node) handleMessage(payload []byte) {
+	msg, err := proto_node.GetMessage(payload)
+	if err != nil {
+		return
+	}
+
+	switch msg.Type {
+	case proto_node.Transaction:
+		// A batch of txs to accept, e.g. from txgen: verify and announce each into the
+		// mempool before whatever mines them picks them up.
+		for _, tx := range msg.Transactions {
+			node.AcceptTx(tx, node.ClientPeers)
+		}
+	case proto_node.MempoolAnnounce:
+		if node.Client != nil && node.Client.UpdateMempool != nil {
+			node.Client.UpdateMempool(msg.Transactions)
+		}
+	}
+}
+
+// BroadcastMempoolAnnounce tells peers (a leader's clients) that node has just accepted tx
+// into its mempool, ahead of it being mined into a block, so they can measure
+// announce-to-include latency instead of only block-level latency.
+func (node *Node) BroadcastMempoolAnnounce(tx *blockchain.Transaction, peers []p2p.Peer) {
+	msg := proto_node.ConstructMempoolAnnounceMessage([]*blockchain.Transaction{tx})
+	p2p.BroadcastMessage(peers, msg)
+}
+
+// AcceptTx is the leader-side entry point a node should call on every incoming transaction: it
+// verifies tx against node's own UtxoPool (the same check Verify's doc comment calls for before
+// including a transaction in a block) and, only if that passes, announces it into the mempool
+// via BroadcastMempoolAnnounce. Returns false, without announcing, if tx fails verification.
+func (node *Node) AcceptTx(tx *blockchain.Transaction, peers []p2p.Peer) bool {
+	if !tx.IsCoinbase() {
+		prevTXs := make(map[string]blockchain.Transaction)
+		for _, in := range tx.TxInput {
+			out, ok := node.UtxoPool.FindOutput(in.TxID[:], in.TxOutIndex)
+			if !ok {
+				return false
+			}
+			outputs := make([]blockchain.TXOutput, in.TxOutIndex+1)
+			outputs[in.TxOutIndex] = out
+			prevTXs[hex.EncodeToString(in.TxID[:])] = blockchain.Transaction{TxOutput: outputs}
+		}
+		if !tx.Verify(prevTXs) {
+			return false
+		}
+	}
+
+	node.BroadcastMempoolAnnounce(tx, peers)
+	return true
+}
+
+// AddNewBlock folds a newly received block's transactions into the node's
+// UTXO pool: spent inputs are removed and new outputs are added.
+func (node *Node) AddNewBlock(block *blockchain.Block) {
+	for _, tx := range block.Transactions {
+		for _, in := range tx.TxInput {
+			for address := range node.UtxoPool.VerifiedTransactions {
+				node.UtxoPool.RemoveOutput(address, in.TxID[:], in.TxOutIndex)
+			}
+		}
+		for index, out := range tx.TxOutput {
+			node.UtxoPool.AddOutput(out.Address, tx.ID, index, out)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package p2p provides the minimal peer-to-peer transport txgen and the nodes use to
+// exchange length-prefixed messages over TCP.
+package p2p
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+
+	"harmony-benchmark/log"
+)
+
+// Peer identifies a node reachable over TCP.
+type Peer struct {
+	IP   string
+	Port string
+}
+
+// SendMessage delivers message to peer over a single TCP connection, prefixed with its
+// 4-byte big-endian length.
+func SendMessage(peer Peer, message []byte) {
+	conn, err := net.Dial("tcp", net.JoinHostPort(peer.IP, peer.Port))
+	if err != nil {
+		log.Warn("p2p: failed to dial peer", "peer", peer, "err", err)
+		return
+	}
+	defer conn.Close()
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(message)))
+	if _, err := conn.Write(length[:]); err != nil {
+		log.Warn("p2p: failed to write message length", "peer", peer, "err", err)
+		return
+	}
+	if _, err := conn.Write(message); err != nil {
+		log.Warn("p2p: failed to write message", "peer", peer, "err", err)
+	}
+}
+
+// BroadcastMessage sends message to every peer in peers.
+func BroadcastMessage(peers []Peer, message []byte) {
+	for _, peer := range peers {
+		SendMessage(peer, message)
+	}
+}
+
+// Listen accepts TCP connections on port for as long as the process runs, reading one
+// length-prefixed message off each connection and passing its payload to handle.
+func Listen(port string, handle func(payload []byte)) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("", port))
+	if err != nil {
+		log.Error("p2p: failed to listen", "port", port, "err", err)
+		return
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Warn("p2p: failed to accept connection", "err", err)
+			continue
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+
+			var length [4]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+
+			payload := make([]byte, binary.BigEndian.Uint32(length[:]))
+			if _, err := io.ReadFull(conn, payload); err != nil {
+				return
+			}
+
+			handle(payload)
+		}(conn)
+	}
+}
@@ -0,0 +1,66 @@
+// Package node (imported elsewhere as proto_node to disambiguate from harmony-benchmark/node)
+// defines the wire messages nodes exchange over p2p: transaction batches, mempool
+// announcements, and the benchmark stop signal.
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"harmony-benchmark/blockchain"
+	"harmony-benchmark/log"
+)
+
+// MessageType identifies the kind of payload a node message carries.
+type MessageType byte
+
+const (
+	// Transaction carries a batch of transactions to be included in a block.
+	Transaction MessageType = iota
+	// MempoolAnnounce carries a batch of transactions a leader has just accepted into its
+	// mempool, ahead of them being mined into a block.
+	MempoolAnnounce
+	// Stop tells a node to shut down.
+	Stop
+)
+
+// Message is the envelope every node message is wrapped in.
+type Message struct {
+	Type         MessageType
+	Transactions []*blockchain.Transaction
+}
+
+func construct(msg Message) []byte {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		log.Error("proto_node: failed to encode message", "type", msg.Type, "err", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// ConstructTransactionListMessage builds a Transaction message carrying txs, e.g. for a
+// leader to mine or a client to learn about.
+func ConstructTransactionListMessage(txs []*blockchain.Transaction) []byte {
+	return construct(Message{Type: Transaction, Transactions: txs})
+}
+
+// ConstructMempoolAnnounceMessage builds a MempoolAnnounce message: txs a leader has just
+// accepted into its mempool, sent to clients so they can measure announce-to-include latency
+// rather than only block-level latency.
+func ConstructMempoolAnnounceMessage(txs []*blockchain.Transaction) []byte {
+	return construct(Message{Type: MempoolAnnounce, Transactions: txs})
+}
+
+// ConstructStopMessage builds a Stop message telling recipients to shut down.
+func ConstructStopMessage() []byte {
+	return construct(Message{Type: Stop})
+}
+
+// GetMessage decodes a wire message previously built by one of the Construct*Message
+// functions.
+func GetMessage(payload []byte) (Message, error) {
+	var msg Message
+	err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&msg)
+	return msg, err
+}